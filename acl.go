@@ -0,0 +1,190 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// AccessIndex 把 Quartz 产物（.md / .html）映射到其 frontmatter 里声明的 access ACL，
+// 这样一篇笔记就能单独控制谁可以看，而不必依赖一份全局的 Casbin 策略文件。
+type AccessIndex struct {
+	mu   sync.RWMutex
+	acls map[string][]string // 输出路径（如 /private/note.html） -> ["role:staff", "user:alice", ...]
+}
+
+// frontmatter 只关心 access 字段，其余 YAML 键值一律忽略。
+type frontmatter struct {
+	Access yaml.Node `yaml:"access"`
+}
+
+// newAccessIndex 扫描 quartzDir 建立一份初始索引，并启动 fsnotify 在 Quartz 重新构建时更新它。
+func newAccessIndex(quartzDir string) (*AccessIndex, error) {
+	idx := &AccessIndex{acls: map[string][]string{}}
+	if err := idx.rebuild(quartzDir); err != nil {
+		return nil, err
+	}
+	if err := idx.watch(quartzDir); err != nil {
+		log.Printf("[ACL] 启动 fsnotify 监听失败，将只使用启动时的索引: %v", err)
+	}
+	return idx, nil
+}
+
+// rebuild 遍历 quartzDir 下所有 .md/.html 文件，重新解析其 frontmatter 并替换索引。
+func (idx *AccessIndex) rebuild(quartzDir string) error {
+	acls := map[string][]string{}
+
+	err := filepath.Walk(quartzDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".md" && ext != ".html" {
+			return nil
+		}
+
+		roles, ok, err := parseAccessACL(path)
+		if err != nil {
+			log.Printf("[ACL] 解析 %s 的 frontmatter 失败，忽略: %v", path, err)
+			return nil
+		}
+		if !ok {
+			return nil
+		}
+
+		rel, err := filepath.Rel(quartzDir, path)
+		if err != nil {
+			return nil
+		}
+		acls["/"+filepath.ToSlash(rel)] = roles
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	idx.acls = acls
+	idx.mu.Unlock()
+	log.Printf("[ACL] 索引重建完成，共 %d 篇笔记带有 access 声明", len(acls))
+	return nil
+}
+
+// watch 用 fsnotify 监听 quartzDir 及其子目录，Quartz 每次 build 后自动刷新索引。
+// fsnotify 本身不支持递归监听，所以需要对每个子目录单独 Add。
+func (idx *AccessIndex) watch(quartzDir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(quartzDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+					continue
+				}
+				if err := idx.rebuild(quartzDir); err != nil {
+					log.Printf("[ACL] 重建索引失败: %v", err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[ACL] fsnotify 出错: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Allowed 判断 sub/roles 是否可以访问 path：没有 ACL 声明的笔记一律公开；
+// ACL 中出现 "public"，或者用户本人（user:<name>）/所属角色（role:<role>）在列表里，则放行。
+func (idx *AccessIndex) Allowed(path, sub string, roles []string) bool {
+	idx.mu.RLock()
+	acl, ok := idx.acls[path]
+	idx.mu.RUnlock()
+	if !ok {
+		return true
+	}
+
+	for _, entry := range acl {
+		switch {
+		case entry == "public":
+			return true
+		case entry == "user:"+sub:
+			return true
+		default:
+			for _, role := range roles {
+				if entry == "role:"+role {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// parseAccessACL 读取文件开头的 YAML frontmatter（--- ... ---）并提取 access 字段。
+// 返回 ok=false 表示该文件没有 frontmatter 或没有声明 access，此时按公开处理。
+func parseAccessACL(path string) ([]string, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	const delim = "---"
+	text := string(data)
+	if !strings.HasPrefix(text, delim) {
+		return nil, false, nil
+	}
+	rest := text[len(delim):]
+	end := strings.Index(rest, "\n"+delim)
+	if end == -1 {
+		return nil, false, nil
+	}
+
+	var fm frontmatter
+	if err := yaml.Unmarshal([]byte(rest[:end]), &fm); err != nil {
+		return nil, false, err
+	}
+	if fm.Access.Kind == 0 {
+		return nil, false, nil
+	}
+
+	switch fm.Access.Kind {
+	case yaml.ScalarNode:
+		return []string{fm.Access.Value}, true, nil
+	case yaml.SequenceNode:
+		roles := make([]string, 0, len(fm.Access.Content))
+		for _, n := range fm.Access.Content {
+			roles = append(roles, n.Value)
+		}
+		return roles, true, nil
+	default:
+		return nil, false, nil
+	}
+}
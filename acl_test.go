@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempNote(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "note.md")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("写入临时笔记失败: %v", err)
+	}
+	return path
+}
+
+func TestParseAccessACLScalar(t *testing.T) {
+	path := writeTempNote(t, "---\naccess: role:staff\n---\n# hello\n")
+
+	roles, ok, err := parseAccessACL(path)
+	if err != nil {
+		t.Fatalf("parseAccessACL 返回了错误: %v", err)
+	}
+	if !ok {
+		t.Fatal("带 access 字段的 frontmatter 应当返回 ok=true")
+	}
+	if len(roles) != 1 || roles[0] != "role:staff" {
+		t.Errorf("roles = %v, want [role:staff]", roles)
+	}
+}
+
+func TestParseAccessACLSequence(t *testing.T) {
+	path := writeTempNote(t, "---\naccess:\n  - role:staff\n  - user:alice\n---\n# hello\n")
+
+	roles, ok, err := parseAccessACL(path)
+	if err != nil {
+		t.Fatalf("parseAccessACL 返回了错误: %v", err)
+	}
+	if !ok {
+		t.Fatal("带 access 字段的 frontmatter 应当返回 ok=true")
+	}
+	if len(roles) != 2 || roles[0] != "role:staff" || roles[1] != "user:alice" {
+		t.Errorf("roles = %v, want [role:staff user:alice]", roles)
+	}
+}
+
+func TestParseAccessACLNoFrontmatter(t *testing.T) {
+	path := writeTempNote(t, "# 没有 frontmatter 的笔记\n")
+
+	_, ok, err := parseAccessACL(path)
+	if err != nil {
+		t.Fatalf("parseAccessACL 返回了错误: %v", err)
+	}
+	if ok {
+		t.Error("没有 frontmatter 时应当返回 ok=false")
+	}
+}
+
+func TestParseAccessACLNoAccessField(t *testing.T) {
+	path := writeTempNote(t, "---\ntitle: hello\n---\n# hello\n")
+
+	_, ok, err := parseAccessACL(path)
+	if err != nil {
+		t.Fatalf("parseAccessACL 返回了错误: %v", err)
+	}
+	if ok {
+		t.Error("frontmatter 中没有 access 字段时应当返回 ok=false")
+	}
+}
+
+func TestAccessIndexAllowed(t *testing.T) {
+	idx := &AccessIndex{acls: map[string][]string{
+		"/private/a.html": {"role:staff"},
+		"/private/b.html": {"user:alice"},
+		"/private/c.html": {"public"},
+	}}
+
+	cases := []struct {
+		name  string
+		path  string
+		sub   string
+		roles []string
+		want  bool
+	}{
+		{"未声明 access 的路径默认公开", "/public.html", "bob", nil, true},
+		{"角色匹配放行", "/private/a.html", "bob", []string{"staff"}, true},
+		{"角色不匹配拒绝", "/private/a.html", "bob", []string{"guest"}, false},
+		{"用户本人放行", "/private/b.html", "alice", nil, true},
+		{"非本人拒绝", "/private/b.html", "bob", nil, false},
+		{"public 声明对所有人放行", "/private/c.html", "bob", nil, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := idx.Allowed(tc.path, tc.sub, tc.roles); got != tc.want {
+				t.Errorf("Allowed(%q, %q, %v) = %v, want %v", tc.path, tc.sub, tc.roles, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package authz 提供基于 Casbin 的按路径授权能力，
+// 策略来自 Casdoor 用户的角色（role）与一份可热更新的 CSV 策略文件。
+package authz
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// Enforcer 包装 casbin.Enforcer，支持在收到 SIGHUP 时重新加载策略文件，
+// 这样管理员可以直接编辑 PolicyPath 而无需重启网关。
+type Enforcer struct {
+	mu         sync.RWMutex
+	enforcer   *casbin.Enforcer
+	modelPath  string
+	policyPath string
+}
+
+// New 加载 modelPath 指向的 RBAC 模型（见仓库自带的 authz/model.conf）和
+// policyPath 指向的 CSV 策略，并开始监听 SIGHUP 以便热重载。
+func New(modelPath, policyPath string) (*Enforcer, error) {
+	e := &Enforcer{modelPath: modelPath, policyPath: policyPath}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+	e.watchReload()
+	return e, nil
+}
+
+func (e *Enforcer) reload() error {
+	enforcer, err := casbin.NewEnforcer(e.modelPath, e.policyPath)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.enforcer = enforcer
+	e.mu.Unlock()
+	return nil
+}
+
+// watchReload 在收到 SIGHUP 时重新加载策略文件，失败时保留旧策略继续生效。
+func (e *Enforcer) watchReload() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	go func() {
+		for range sigCh {
+			log.Println("[AUTHZ] 收到 SIGHUP，重新加载 Casbin 策略")
+			if err := e.reload(); err != nil {
+				log.Printf("[AUTHZ] 策略重载失败，继续使用旧策略: %v", err)
+			}
+		}
+	}()
+}
+
+// Enforce 判断 sub（用户或角色）是否有权限对 obj（请求路径）执行 act（如 GET）。
+func (e *Enforcer) Enforce(sub, obj, act string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ok, err := e.enforcer.Enforce(sub, obj, act)
+	if err != nil {
+		log.Printf("[AUTHZ] Enforce 出错，默认拒绝: %v", err)
+		return false
+	}
+	return ok
+}
+
+// EnforceAny 只要 sub 本人或其任一角色对 obj/act 有权限就放行，
+// 用于同时支持「按用户名」与「按角色」两种策略写法。
+func (e *Enforcer) EnforceAny(sub string, roles []string, obj, act string) bool {
+	if e.Enforce(sub, obj, act) {
+		return true
+	}
+	for _, role := range roles {
+		if e.Enforce("role:"+role, obj, act) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package authz
+
+import "testing"
+
+func newTestEnforcer(t *testing.T) *Enforcer {
+	t.Helper()
+	e, err := New("model.conf", "policy.csv")
+	if err != nil {
+		t.Fatalf("New 返回了错误: %v", err)
+	}
+	return e
+}
+
+func TestEnforceAnyAllowsThroughRoleInheritance(t *testing.T) {
+	e := newTestEnforcer(t)
+
+	if !e.EnforceAny("alice", []string{"staff"}, "/notes/a.html", "GET") {
+		t.Error("role:staff 通过 g 继承了 role:public，应当被放行")
+	}
+}
+
+func TestEnforceAnyDeniesUnknownRole(t *testing.T) {
+	e := newTestEnforcer(t)
+
+	if e.EnforceAny("alice", []string{"guest"}, "/notes/a.html", "GET") {
+		t.Error("guest 既不是 sub 本人也没有匹配策略，应当被拒绝")
+	}
+}
+
+func TestEnforceAnyAllowsDirectSubjectMatch(t *testing.T) {
+	e := newTestEnforcer(t)
+
+	if !e.EnforceAny("role:public", nil, "/notes/a.html", "GET") {
+		t.Error("role:public 本身即是策略里的 sub，应当被放行")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// cacheEntry 是 ResponseCache 里保存的一份完整响应：状态码、响应头和响应体。
+type cacheEntry struct {
+	key        string
+	statusCode int
+	header     http.Header
+	body       []byte
+	etag       string
+	expiresAt  time.Time
+}
+
+// ResponseCache 是按 (path, 是否接受 gzip) 做 key 的 LRU 响应缓存。
+// 命中且未过期时直接回放，不用重新读盘或重新请求上游。
+type ResponseCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+func newResponseCache(capacity int, ttl time.Duration) *ResponseCache {
+	if capacity <= 0 {
+		capacity = 500
+	}
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	return &ResponseCache{capacity: capacity, ttl: ttl, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+// cacheKey 让同一路径的 gzip / 非 gzip 响应分开缓存，避免把压缩体发给不支持的客户端。
+func cacheKey(path string, acceptsGzip bool) string {
+	if acceptsGzip {
+		return path + "|gzip"
+	}
+	return path + "|plain"
+}
+
+func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry, true
+}
+
+func (c *ResponseCache) set(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.expiresAt = time.Now().Add(c.ttl)
+	if el, ok := c.items[entry.key]; ok {
+		el.Value = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(entry)
+	c.items[entry.key] = el
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*cacheEntry).key)
+		}
+	}
+}
+
+// computeETag 对响应体算一个强 ETag（sha256），相同内容的重复请求能直接 304。
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestComputeETagDeterministic(t *testing.T) {
+	body := []byte("hello world")
+	if computeETag(body) != computeETag(body) {
+		t.Error("相同内容应当产出相同的 ETag")
+	}
+}
+
+func TestComputeETagDiffersOnContent(t *testing.T) {
+	if computeETag([]byte("a")) == computeETag([]byte("b")) {
+		t.Error("不同内容应当产出不同的 ETag")
+	}
+}
+
+func TestComputeETagIsQuoted(t *testing.T) {
+	etag := computeETag([]byte("hello"))
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		t.Errorf("ETag 应当是强校验格式（带双引号），got %q", etag)
+	}
+}
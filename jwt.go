@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims 是从 Casdoor access_token 中解析出的身份声明，登录后随 Session 一起
+// 保存在 SessionStore 里，浏览器侧只持有指向它的不透明 sid。
+type Claims struct {
+	Sub   string   `json:"sub"`
+	Name  string   `json:"name"`
+	Owner string   `json:"owner"`
+	Roles []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type contextKey string
+
+const claimsContextKey contextKey = "quartzClaims"
+
+// withClaims 把校验通过的 Claims 挂到请求 context 上，供后续 handler 使用。
+func withClaims(r *http.Request, claims *Claims) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claimsContextKey, claims))
+}
+
+// claimsFromRequest 取出 handleMain 在 checkAuth 通过后用 withClaims 挂载的 Claims；未登录场景下返回 nil。
+func claimsFromRequest(r *http.Request) *Claims {
+	claims, _ := r.Context().Value(claimsContextKey).(*Claims)
+	return claims
+}
+
+// jwksCache 缓存 Casdoor 的 JWKS 公钥，避免每次校验 access_token 都去拉取。
+type jwksCache struct {
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+var casdoorJWKS = &jwksCache{keys: map[string]*rsa.PublicKey{}}
+
+type jwkKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwkSet struct {
+	Keys []jwkKey `json:"keys"`
+}
+
+// publicKey 按 kid 返回 Casdoor 的 RSA 公钥：缓存不满一小时且命中 kid 时直接返回，
+// 否则（缓存已满一小时，或 kid 不在缓存中）重新拉取 JWKS。
+func (c *jwksCache) publicKey(kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.keys[kid]; ok && time.Since(c.fetchedAt) < time.Hour {
+		return key, nil
+	}
+
+	resp, err := http.Get(conf.CasdoorAddr + "/.well-known/jwks")
+	if err != nil {
+		return nil, fmt.Errorf("拉取 Casdoor JWKS 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("解析 JWKS 失败: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := parseRSAPublicKey(k.N, k.E)
+		if err != nil {
+			log.Printf("[JWT] 忽略无法解析的 JWKS key %s: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	c.keys = keys
+	c.fetchedAt = time.Now()
+
+	key, ok := c.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("JWKS 中找不到 kid=%s", kid)
+	}
+	return key, nil
+}
+
+func parseRSAPublicKey(nEnc, eEnc string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(nEnc)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(eEnc)
+	if err != nil {
+		return nil, err
+	}
+
+	e := 0
+	for _, b := range eBytes {
+		e = e<<8 + int(b)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: e}, nil
+}
+
+// verifyCasdoorToken 校验从 Casdoor 换取的 access_token（RS256 签名 + 有效期），
+// 返回其中携带的标准用户声明。
+func verifyCasdoorToken(rawToken string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return casdoorJWKS.publicKey(kid)
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithAudience(conf.ClientID))
+	if err != nil {
+		return nil, fmt.Errorf("校验 Casdoor access_token 失败: %w", err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseRSAPublicKey(t *testing.T) {
+	// RSA-1024 公钥 (n, e) 的 base64url 编码，标准指数 65537 = "AQAB"。
+	const n = "ALsxFLXdq9iFzJ0KSmrjwFbHkCcoaZuDKd1qt6lAGMtRCpiBn2NW31CH8SV1h3Y0KKf3GKtMLZtxJ3fGb0WH2Kkj9XdRBVVv8i8oHNAA" +
+		"Wn6ESYJq0SknvJxFiW9B31GmzpY8y6KYLw2LQqohzEWSLTE4kOhC2JzU6ffC7b6Lzefn"
+	const e = "AQAB"
+
+	key, err := parseRSAPublicKey(n, e)
+	if err != nil {
+		t.Fatalf("parseRSAPublicKey 返回了错误: %v", err)
+	}
+	if key.E != 65537 {
+		t.Errorf("E = %d, want 65537", key.E)
+	}
+	if key.N == nil || key.N.Sign() <= 0 {
+		t.Errorf("N 未被正确解析为正整数")
+	}
+}
+
+func TestParseRSAPublicKeyInvalidEncoding(t *testing.T) {
+	if _, err := parseRSAPublicKey("not-base64!!", "AQAB"); err == nil {
+		t.Error("N 不是合法 base64url 时应当返回错误")
+	}
+	if _, err := parseRSAPublicKey("AQAB", "not-base64!!"); err == nil {
+		t.Error("E 不是合法 base64url 时应当返回错误")
+	}
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/skydtrtzmr/go-casdoor-static-server/authz"
 )
 
 type Config struct {
@@ -21,15 +24,90 @@ type Config struct {
 	ClientSecret string `json:"client_secret"`
 	AppName      string `json:"app_name"`
 	RedirectPath string `json:"redirect_path"`
+
+	// SessionSecret 预留给其他需要签名的场景（如登录态相关的短期 Cookie）。
+	SessionSecret string `json:"session_secret"`
+	// SessionTTLSeconds 是 Session 的有效期，默认 1 小时。
+	SessionTTLSeconds int `json:"session_ttl_seconds"`
+
+	// ModelPath / PolicyPath 指向 Casbin 的 RBAC 模型与策略 CSV，用于按路径授权。
+	ModelPath  string `json:"model_path"`
+	PolicyPath string `json:"policy_path"`
+
+	// SessionBackend 选择 Session 的存储后端："memory"（默认）或 "redis"。
+	SessionBackend string `json:"session_backend"`
+	RedisAddr      string `json:"redis_addr"`
+	RedisPassword  string `json:"redis_password"`
+
+	// TrustedRedirectHosts 是登录后允许跳回的外部 Host 白名单，防止 state.original_url 被用来做开放重定向。
+	TrustedRedirectHosts []string `json:"trusted_redirect_hosts"`
+
+	// Mode 为 "static"（默认，直接读盘）或 "proxy"（反向代理到 UpstreamAddr，
+	// 用于接 `npx quartz build --serve` 这类开发服务器）。
+	Mode         string `json:"mode"`
+	UpstreamAddr string `json:"upstream_addr"`
+
+	// CacheSize 是响应缓存的最大条目数，CacheTTLSeconds 是缓存有效期，均默认见 cacheTTL/newResponseCache。
+	CacheSize       int `json:"cache_size"`
+	CacheTTLSeconds int `json:"cache_ttl_seconds"`
 }
 
 var conf Config
 
+var enforcer *authz.Enforcer
+var sessionStore SessionStore
+var accessIndex *AccessIndex
+
+// sessionTTL 返回配置的会话有效期，未配置时默认为 1 小时。
+func (c Config) sessionTTL() time.Duration {
+	if c.SessionTTLSeconds <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.SessionTTLSeconds) * time.Second
+}
+
+// cacheTTL 返回响应缓存的有效期，未配置时默认为 1 分钟。
+func (c Config) cacheTTL() time.Duration {
+	if c.CacheTTLSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(c.CacheTTLSeconds) * time.Second
+}
+
 func main() {
 	loadConfig()
 
+	e, err := authz.New(conf.ModelPath, conf.PolicyPath)
+	if err != nil {
+		log.Fatalf("❌ 加载 Casbin 策略失败: %v", err)
+	}
+	enforcer = e
+
+	store, err := newSessionStore()
+	if err != nil {
+		log.Fatalf("❌ 初始化 SessionStore 失败: %v", err)
+	}
+	sessionStore = store
+
+	idx, err := newAccessIndex(conf.QuartzDir)
+	if err != nil {
+		log.Fatalf("❌ 构建笔记 ACL 索引失败: %v", err)
+	}
+	accessIndex = idx
+
+	initResponseCache()
+	if conf.Mode == "proxy" {
+		proxy, err := newQuartzProxy(conf.UpstreamAddr)
+		if err != nil {
+			log.Fatalf("❌ 初始化反向代理失败: %v", err)
+		}
+		quartzProxy = proxy
+	}
+
 	http.HandleFunc("/callback", handleCallback)
 	http.HandleFunc("/logout", handleLogout)
+	http.HandleFunc("/refresh", handleRefresh)
+	http.HandleFunc("/whoami", handleWhoami)
 	http.HandleFunc("/", handleMain)
 
 	log.Printf("🚀 Quartz 网关已启动: %s", conf.BaseURL)
@@ -47,8 +125,9 @@ func handleMain(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 2. 【核心拦截逻辑】
-	// 如果用户没有合法的 Cookie (quartz_session)
-	if !checkAuth(r) {
+	// 如果用户没有合法的 sid Cookie，或者对应的 Session 已失效/被登出
+	claims, ok := checkAuth(r)
+	if !ok {
 		// A. 如果用户请求的是 JS/CSS/JSON 等资源文件
 		// 我们不能重定向到登录页，否则浏览器解析 HTML 登录页时会报错（Unexpected token '<'）
 		if isStaticResource(urlPath) {
@@ -63,8 +142,16 @@ func handleMain(w http.ResponseWriter, r *http.Request) {
 		redirectToLogin(w, r)
 		return
 	}
+	r = withClaims(r, claims)
+
+	// 2.1 【按路径授权】已登录不代表什么都能看，还要过 Casbin 这一关
+	if !enforcer.EnforceAny(claims.Name, claims.Roles, urlPath, "GET") {
+		log.Printf("[BLOCK] 用户 %s 无权访问 %s", claims.Name, urlPath)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
 
-	// --- 以下逻辑仅在【已登录】状态下执行 ---
+	// --- 以下逻辑仅在【已登录且已授权】状态下执行 ---
 
 	// 3. 【路径补全逻辑】
 	// 处理 Quartz 这种静态站点的 URL 特性
@@ -76,8 +163,21 @@ func handleMain(w http.ResponseWriter, r *http.Request) {
 		finalPath = urlPath + ".html"
 	}
 
-	// 4. 【正式交付文件】
-	// 从本地磁盘读取文件并返回给浏览器
+	// 3.1 【按笔记授权】Casbin 策略之外，单篇笔记还可以在 frontmatter 里用
+	// access: 声明自己的 ACL（没有声明则视为公开）
+	if !accessIndex.Allowed(finalPath, claims.Name, claims.Roles) {
+		log.Printf("[BLOCK] 用户 %s 无权访问笔记 %s", claims.Name, finalPath)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// 4. 【正式交付内容】
+	// static 模式下直接读盘，proxy 模式下转发给上游的 Quartz 开发服务器；
+	// 两种模式都走同一套响应缓存 + ETag 逻辑。
+	if conf.Mode == "proxy" {
+		serveProxy(w, r)
+		return
+	}
 	serveQuartzFile(w, r, finalPath)
 }
 
@@ -90,82 +190,164 @@ func handleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. 去 Casdoor 换取真实的用户名
-	realUsername := fetchRealUsername(code)
+	// 1. 校验 CSRF state，并取出配套的 PKCE code_verifier / 原始目标 URL
+	savedState, err := readOAuthStateCookie(r)
+	if err != nil {
+		log.Printf("[AUTH] oauth_state 读取失败: %v", err)
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("state") != savedState.State {
+		log.Printf("[AUTH] state 不匹配，拒绝回调")
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+	clearCookie(w, "oauth_state", "/callback")
+
+	// 2. 用授权码 + code_verifier 向 Casdoor 换取 access_token / refresh_token
+	token, err := exchangeCodeForToken(code, savedState.Verifier)
+	if err != nil {
+		log.Printf("[AUTH] Token 换取失败: %v", err)
+		http.Error(w, "Login failed", http.StatusBadGateway)
+		return
+	}
+
+	// 3. 校验 access_token 的签名与有效期，取出 Casdoor 的身份声明
+	casdoorClaims, err := verifyCasdoorToken(token.AccessToken)
+	if err != nil {
+		log.Printf("[AUTH] access_token 校验失败: %v", err)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
 
-	// 2. 写入 Session Cookie (保镖用)
+	// 4. 拉取用户在 Casdoor 的角色/分组，供 Casbin 按路径授权使用
+	roles, err := fetchUserRoles(token.AccessToken, casdoorClaims.Owner, casdoorClaims.Name)
+	if err != nil {
+		log.Printf("[AUTH] 拉取用户角色失败，将以无角色状态登录: %v", err)
+	} else {
+		casdoorClaims.Roles = roles
+	}
+
+	// 5. 把身份声明存进 SessionStore，浏览器只拿到一个不透明的 sid
+	sid, err := sessionStore.Create(casdoorClaims)
+	if err != nil {
+		log.Printf("[AUTH] 创建 Session 失败: %v", err)
+		http.Error(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
 	http.SetCookie(w, &http.Cookie{
-		Name:     "quartz_session",
-		Value:    "is_authenticated",
+		Name:     "sid",
+		Value:    sid,
 		Path:     "/",
 		HttpOnly: true,
-		MaxAge:   3600 * 24 * 7,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(conf.sessionTTL().Seconds()),
 	})
 
-	// 3. 写入展示用的用户名 (给 Quartz 组件用)
-	// 记得编码，防止中文导致 'å' 报错
+	// 6. refresh_token 只给 /refresh 端点用，保持 HttpOnly
 	http.SetCookie(w, &http.Cookie{
-		Name:     "quartz_username",
-		Value:    url.QueryEscape(realUsername),
-		Path:     "/",
-		HttpOnly: false,
+		Name:     "quartz_refresh",
+		Value:    token.RefreshToken,
+		Path:     "/refresh",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 		MaxAge:   3600 * 24 * 7,
 	})
 
-	log.Printf("[AUTH] 用户 %s 登录成功，正在跳转首页", realUsername)
-	http.Redirect(w, r, "/", http.StatusFound)
+	redirectTarget := safeRedirectTarget(savedState.OriginalURL)
+	log.Printf("[AUTH] 用户 %s 登录成功，正在跳转回 %s", casdoorClaims.Name, redirectTarget)
+	http.Redirect(w, r, redirectTarget, http.StatusFound)
 }
 
-// 核心：调用 Casdoor 接口获取账号信息
-func fetchRealUsername(code string) string {
-	// 构造换取 token 的请求
-	// 注意：这里为了保持代码精简，使用 Casdoor 提供的简易验证逻辑
-	// 实际生产中建议使用 Casdoor SDK
-	tokenURL := fmt.Sprintf("%s/api/login/oauth/access_token", conf.CasdoorAddr)
-
-	resp, err := http.PostForm(tokenURL, url.Values{
-		"grant_type":    {"authorization_code"},
-		"client_id":     {conf.ClientID},
-		"client_secret": {conf.ClientSecret},
-		"code":          {code},
-	})
+// handleRefresh 在会话临近过期前，用 quartz_refresh 换取新的 access_token，
+// 并在 SessionStore 里重新建立一份 Session（旧 sid 随之失效）。
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("quartz_refresh")
+	if err != nil {
+		http.Error(w, "No refresh token", http.StatusUnauthorized)
+		return
+	}
 
+	token, err := refreshToken(cookie.Value)
 	if err != nil {
-		log.Printf("Token 换取失败: %v", err)
-		return "Guest"
+		log.Printf("[AUTH] refresh_token 续期失败: %v", err)
+		http.Error(w, "Refresh failed", http.StatusUnauthorized)
+		return
 	}
-	defer resp.Body.Close()
 
-	// 解析返回的 JSON
-	var data struct {
-		AccessToken string `json:"access_token"`
+	casdoorClaims, err := verifyCasdoorToken(token.AccessToken)
+	if err != nil {
+		log.Printf("[AUTH] 续期后的 access_token 校验失败: %v", err)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	if roles, err := fetchUserRoles(token.AccessToken, casdoorClaims.Owner, casdoorClaims.Name); err != nil {
+		log.Printf("[AUTH] 续期时拉取用户角色失败，沿用旧角色: %v", err)
+		if old, err := r.Cookie("sid"); err == nil {
+			if sess, err := sessionStore.Get(old.Value); err == nil && sess.Claims != nil {
+				casdoorClaims.Roles = sess.Claims.Roles
+			}
+		}
+	} else {
+		casdoorClaims.Roles = roles
 	}
-	json.NewDecoder(resp.Body).Decode(&data)
 
-	// Casdoor 的 AccessToken 是 JWT 格式，里面包含了用户名
-	// 我们可以简单地解析 JWT 的中间部分（Payload）
-	parts := strings.Split(data.AccessToken, ".")
-	if len(parts) < 2 {
-		return "Guest"
+	if old, err := r.Cookie("sid"); err == nil {
+		sessionStore.Delete(old.Value)
 	}
 
-	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
-	var claims struct {
-		Name string `json:"name"` // Casdoor 默认在 name 字段存用户名
-		ID   string `json:"id"`   // 有些配置下是 id
+	sid, err := sessionStore.Create(casdoorClaims)
+	if err != nil {
+		log.Printf("[AUTH] 会话续期失败: %v", err)
+		http.Error(w, "Refresh failed", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "sid",
+		Value:    sid,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(conf.sessionTTL().Seconds()),
+	})
+
+	if token.RefreshToken != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "quartz_refresh",
+			Value:    token.RefreshToken,
+			Path:     "/refresh",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   3600 * 24 * 7,
+		})
 	}
-	json.NewDecoder(strings.NewReader(string(payload))).Decode(&claims)
 
-	if claims.Name != "" {
-		return claims.Name
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWhoami 把当前会话的身份声明以 JSON 形式交给 Quartz 组件，
+// 取代过去直接读取明文 quartz_username cookie 的做法。
+func handleWhoami(w http.ResponseWriter, r *http.Request) {
+	claims, ok := checkAuth(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
-	return claims.ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
 }
 
 func handleLogout(w http.ResponseWriter, r *http.Request) {
+	// 立即吊销服务端的 Session，这样被复制走的 sid 也无法再使用
+	if sid, err := r.Cookie("sid"); err == nil {
+		if err := sessionStore.Delete(sid.Value); err != nil {
+			log.Printf("[AUTH] 登出时删除 Session 失败: %v", err)
+		}
+	}
+
 	// 清除所有本地 Cookie
-	clearCookie(w, "quartz_session")
-	clearCookie(w, "quartz_username")
+	clearCookie(w, "sid", "/")
+	clearCookie(w, "quartz_refresh", "/refresh")
 
 	// 动态拼接 Casdoor 退出地址
 	logoutURL := fmt.Sprintf("%s/api/logout?redirect_uri=%s",
@@ -177,24 +359,60 @@ func handleLogout(w http.ResponseWriter, r *http.Request) {
 // ---------------- 辅助函数  ----------------
 
 func redirectToLogin(w http.ResponseWriter, r *http.Request) {
-	loginURL := fmt.Sprintf("%s/login/oauth/authorize?client_id=%s&response_type=code&redirect_uri=%s&scope=read&state=%s",
-		conf.CasdoorAddr, conf.ClientID, url.QueryEscape(conf.RedirectPath), conf.AppName)
+	state, challenge, err := newOAuthState(r.URL.RequestURI())
+	if err != nil {
+		log.Printf("[AUTH] 生成 OAuth state/PKCE 失败: %v", err)
+		http.Error(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
+	if err := setOAuthStateCookie(w, state); err != nil {
+		log.Printf("[AUTH] 写入 oauth_state cookie 失败: %v", err)
+		http.Error(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
+
+	loginURL := fmt.Sprintf(
+		"%s/login/oauth/authorize?client_id=%s&response_type=code&redirect_uri=%s&scope=read&state=%s&code_challenge=%s&code_challenge_method=S256",
+		conf.CasdoorAddr, conf.ClientID, url.QueryEscape(conf.RedirectPath), url.QueryEscape(state.State), url.QueryEscape(challenge))
 	http.Redirect(w, r, loginURL, http.StatusFound)
 }
 
 func serveQuartzFile(w http.ResponseWriter, r *http.Request, relPath string) {
 	fullPath := filepath.Join(conf.QuartzDir, filepath.FromSlash(strings.TrimPrefix(relPath, "/")))
-	http.ServeFile(w, r, fullPath)
+
+	serveCached(w, r, relPath, func() (int, http.Header, []byte, error) {
+		body, err := os.ReadFile(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return http.StatusNotFound, nil, []byte("404 page not found"), nil
+			}
+			return 0, nil, nil, err
+		}
+		header := http.Header{"Content-Type": {mime.TypeByExtension(filepath.Ext(fullPath))}}
+		return http.StatusOK, header, body, nil
+	})
 }
 
-func checkAuth(r *http.Request) bool {
-	cookie, err := r.Cookie("quartz_session")
-	return err == nil && cookie.Value == "is_authenticated"
+// checkAuth 用 sid 去 SessionStore 查找登录态；查不到（包括被登出/封号删除的情况）
+// 一律视为未登录。
+func checkAuth(r *http.Request) (*Claims, bool) {
+	cookie, err := r.Cookie("sid")
+	if err != nil {
+		return nil, false
+	}
+	session, err := sessionStore.Get(cookie.Value)
+	if err != nil {
+		if err != ErrSessionNotFound {
+			log.Printf("[AUTH] 查询 Session 失败: %v", err)
+		}
+		return nil, false
+	}
+	return session.Claims, true
 }
 
-func clearCookie(w http.ResponseWriter, name string) {
+func clearCookie(w http.ResponseWriter, name, path string) {
 	http.SetCookie(w, &http.Cookie{
-		Name: name, Value: "", Path: "/", MaxAge: -1,
+		Name: name, Value: "", Path: path, MaxAge: -1,
 	})
 }
 
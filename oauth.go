@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// tokenResponse 是 Casdoor /api/login/oauth/access_token 返回的结构。
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// exchangeCodeForToken 用授权码 + PKCE code_verifier 换取 Casdoor 的 access_token / refresh_token。
+func exchangeCodeForToken(code, codeVerifier string) (*tokenResponse, error) {
+	return requestToken(url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {conf.ClientID},
+		"client_secret": {conf.ClientSecret},
+		"code":          {code},
+		"code_verifier": {codeVerifier},
+	})
+}
+
+// refreshToken 用 refresh_token 换取新的 access_token，供会话临期续期使用。
+func refreshToken(refreshToken string) (*tokenResponse, error) {
+	return requestToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {conf.ClientID},
+		"client_secret": {conf.ClientSecret},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// casdoorUser 是 /api/get-user 响应中我们关心的那部分字段。
+type casdoorUser struct {
+	Status string `json:"status"`
+	Data   struct {
+		Name   string   `json:"name"`
+		Owner  string   `json:"owner"`
+		Groups []string `json:"groups"`
+		Roles  []struct {
+			Name string `json:"name"`
+		} `json:"roles"`
+	} `json:"data"`
+}
+
+// fetchUserRoles 用 access_token 调用 Casdoor 的 /api/get-user，取出用户的角色/分组列表，
+// 供 Casbin 的 g(r.sub, p.sub) 角色匹配使用。
+func fetchUserRoles(accessToken, owner, name string) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%s/api/get-user?owner=%s&name=%s", conf.CasdoorAddr, url.QueryEscape(owner), url.QueryEscape(name)),
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 Casdoor /api/get-user 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var user casdoorUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("解析 /api/get-user 响应失败: %w", err)
+	}
+	if user.Status != "ok" {
+		return nil, fmt.Errorf("Casdoor /api/get-user 返回非 ok 状态: %s", user.Status)
+	}
+
+	roles := make([]string, 0, len(user.Data.Roles)+len(user.Data.Groups))
+	for _, role := range user.Data.Roles {
+		roles = append(roles, role.Name)
+	}
+	roles = append(roles, user.Data.Groups...)
+	return roles, nil
+}
+
+func requestToken(form url.Values) (*tokenResponse, error) {
+	tokenURL := fmt.Sprintf("%s/api/login/oauth/access_token", conf.CasdoorAddr)
+
+	resp, err := http.PostForm(tokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("请求 Casdoor token 接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var data tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("解析 token 响应失败: %w", err)
+	}
+	if data.AccessToken == "" {
+		log.Printf("[AUTH] Casdoor 未返回 access_token，响应: %+v", data)
+		return nil, fmt.Errorf("Casdoor 未返回 access_token")
+	}
+	return &data, nil
+}
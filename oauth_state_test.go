@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestSafeRedirectTarget(t *testing.T) {
+	conf.TrustedRedirectHosts = []string{"trusted.example.com"}
+
+	cases := []struct {
+		name     string
+		original string
+		want     string
+	}{
+		{"空字符串回到首页", "", "/"},
+		{"普通站内相对路径放行", "/notes/a.html", "/notes/a.html"},
+		{"协议相对地址被拒绝", "//evil.com/x", "/"},
+		{"反斜杠伪装的协议相对地址被拒绝", "/\\evil.com/x", "/"},
+		{"反斜杠在路径中任意位置都被拒绝", "/a\\b", "/"},
+		{"绝对 URL 命中白名单 Host 时放行", "https://trusted.example.com/x", "https://trusted.example.com/x"},
+		{"绝对 URL 未命中白名单时拒绝", "https://evil.com/x", "/"},
+		{"无法解析的 URL 拒绝", "http://[::1", "/"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := safeRedirectTarget(tc.original); got != tc.want {
+				t.Errorf("safeRedirectTarget(%q) = %q, want %q", tc.original, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+var responseCache *ResponseCache
+var quartzProxy *httputil.ReverseProxy
+
+func initResponseCache() {
+	responseCache = newResponseCache(conf.CacheSize, conf.cacheTTL())
+}
+
+// newQuartzProxy 构造指向 conf.UpstreamAddr 的反向代理，
+// 用于 Mode=="proxy" 时把请求转发给 `npx quartz build --serve` 这类开发服务器。
+func newQuartzProxy(upstream string) (*httputil.ReverseProxy, error) {
+	target, err := url.Parse(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("解析 upstream_addr 失败: %w", err)
+	}
+	return httputil.NewSingleHostReverseProxy(target), nil
+}
+
+func acceptsGzip(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+}
+
+// cacheableResponseHeaders 是允许被写入共享缓存条目、回放给其他请求者的响应头白名单。
+// 像 Set-Cookie、WWW-Authenticate 这类与具体请求者身份绑定的头一律不缓存，
+// 否则第一个填充缓存的用户的凭证会被回放给之后命中同一条目的所有人。
+var cacheableResponseHeaders = map[string]bool{
+	"Content-Type":     true,
+	"Content-Encoding": true,
+	"Content-Language": true,
+	"Cache-Control":    true,
+	"Last-Modified":    true,
+}
+
+// filterCacheableHeaders 只保留白名单内的响应头，用于写入缓存条目。
+func filterCacheableHeaders(header http.Header) http.Header {
+	filtered := make(http.Header, len(header))
+	for k, vs := range header {
+		if cacheableResponseHeaders[http.CanonicalHeaderKey(k)] {
+			filtered[k] = vs
+		}
+	}
+	return filtered
+}
+
+// serveCached 先查缓存并处理 If-None-Match；未命中时调用 fetch 产出响应，写回客户端的同时存入缓存。
+func serveCached(w http.ResponseWriter, r *http.Request, path string, fetch func() (status int, header http.Header, body []byte, err error)) {
+	key := cacheKey(path, acceptsGzip(r))
+
+	entry, ok := responseCache.get(key)
+	if !ok {
+		status, header, body, err := fetch()
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		entry = &cacheEntry{key: key, statusCode: status, header: filterCacheableHeaders(header), body: body, etag: computeETag(body)}
+		responseCache.set(entry)
+	}
+
+	writeCachedResponse(w, r, entry)
+}
+
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, entry *cacheEntry) {
+	for k, vs := range entry.header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("ETag", entry.etag)
+	// 内容因登录用户（笔记 ACL）而异，不能被共享缓存复用，只能被浏览器私有缓存。
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(responseCache.ttl.Seconds())))
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && inm == entry.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	status := entry.statusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(entry.body)
+}
+
+// serveProxy 把请求交给 quartzProxy 转发到上游，并把结果接入 serveCached 的缓存 + ETag 逻辑。
+// 上游是通用反向代理目标，响应可能随查询串变化，缓存路径必须带上 RawQuery 以免串台。
+func serveProxy(w http.ResponseWriter, r *http.Request) {
+	cachePath := r.URL.Path
+	if r.URL.RawQuery != "" {
+		cachePath += "?" + r.URL.RawQuery
+	}
+	serveCached(w, r, cachePath, func() (int, http.Header, []byte, error) {
+		rec := httptest.NewRecorder()
+		quartzProxy.ServeHTTP(rec, r)
+		return rec.Code, rec.Header(), rec.Body.Bytes(), nil
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFilterCacheableHeadersDropsPerRequesterHeaders(t *testing.T) {
+	header := http.Header{
+		"Content-Type":     {"text/html"},
+		"Set-Cookie":       {"session=abc123"},
+		"Www-Authenticate": {"Basic"},
+	}
+
+	filtered := filterCacheableHeaders(header)
+
+	if filtered.Get("Content-Type") != "text/html" {
+		t.Error("白名单内的 Content-Type 应当被保留")
+	}
+	if _, ok := filtered["Set-Cookie"]; ok {
+		t.Error("Set-Cookie 是请求者私有的，不应当被缓存并回放给其他人")
+	}
+	if _, ok := filtered["Www-Authenticate"]; ok {
+		t.Error("Www-Authenticate 不应当被缓存")
+	}
+}
+
+func TestFilterCacheableHeadersKeepsWhitelist(t *testing.T) {
+	header := http.Header{
+		"Content-Type":  {"application/json"},
+		"Cache-Control": {"no-store"},
+		"Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"},
+	}
+
+	filtered := filterCacheableHeaders(header)
+
+	for k := range header {
+		if filtered.Get(k) != header.Get(k) {
+			t.Errorf("白名单内的头 %s 应当原样保留", k)
+		}
+	}
+}
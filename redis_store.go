@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 把 Session 存在 Redis 里，适合多实例部署，登出/封号对所有实例立即生效。
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func newRedisStore(addr, password string, ttl time.Duration) *RedisStore {
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{Addr: addr, Password: password}),
+		ttl:    ttl,
+	}
+}
+
+func (s *RedisStore) key(id string) string {
+	return "quartz_session:" + id
+}
+
+func (s *RedisStore) Create(claims *Claims) (string, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(&Session{Claims: claims, ExpiresAt: time.Now().Add(s.ttl)})
+	if err != nil {
+		return "", err
+	}
+	if err := s.client.Set(context.Background(), s.key(id), data, s.ttl).Err(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (s *RedisStore) Get(id string) (*Session, error) {
+	data, err := s.client.Get(context.Background(), s.key(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func (s *RedisStore) Delete(id string) error {
+	return s.client.Del(context.Background(), s.key(id)).Err()
+}
+
+func (s *RedisStore) Touch(id string) error {
+	return s.client.Expire(context.Background(), s.key(id), s.ttl).Err()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Session 是 SessionStore 中保存的一份登录态。
+type Session struct {
+	Claims    *Claims   `json:"claims"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ErrSessionNotFound 在 sid 不存在或已过期时返回。
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore 是服务端会话存储的抽象。相比把状态全部塞进签名 Cookie，
+// 服务端持有 Session 能让登出/封号立即在所有设备上生效，而不用等 Cookie 自然过期。
+type SessionStore interface {
+	// Create 保存一份新的 Session，返回客户端应当持有的不透明 sid。
+	Create(claims *Claims) (id string, err error)
+	Get(id string) (*Session, error)
+	Delete(id string) error
+	// Touch 延长一个仍然有效的 Session 的过期时间。
+	Touch(id string) error
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// newSessionStore 根据 conf.SessionBackend 构造对应的 SessionStore 实现。
+func newSessionStore() (SessionStore, error) {
+	switch conf.SessionBackend {
+	case "redis":
+		return newRedisStore(conf.RedisAddr, conf.RedisPassword, conf.sessionTTL()), nil
+	case "", "memory":
+		return newMemoryStore(conf.sessionTTL()), nil
+	default:
+		return nil, fmt.Errorf("未知的 session_backend: %s", conf.SessionBackend)
+	}
+}
+
+// sweepInterval 是 MemoryStore 后台清理过期 Session 的间隔。
+const sweepInterval = time.Minute
+
+// MemoryStore 是进程内的 SessionStore 实现，适合单实例部署或本地开发。
+type MemoryStore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	data map[string]*Session
+}
+
+func newMemoryStore(ttl time.Duration) *MemoryStore {
+	s := &MemoryStore{ttl: ttl, data: map[string]*Session{}}
+	go s.sweepExpired()
+	return s
+}
+
+// sweepExpired 定期清理已过期但一直没被 Get 命中的 Session，
+// 否则没人再访问的会话会一直占着内存，Redis 后端靠 key TTL 自动释放，这里得自己做。
+func (s *MemoryStore) sweepExpired() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.sweepOnce()
+	}
+}
+
+// sweepOnce 执行一次过期清理，从 sweepExpired 的定时循环里调用。
+func (s *MemoryStore) sweepOnce() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sess := range s.data {
+		if now.After(sess.ExpiresAt) {
+			delete(s.data, id)
+		}
+	}
+}
+
+func (s *MemoryStore) Create(claims *Claims) (string, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.data[id] = &Session{Claims: claims, ExpiresAt: time.Now().Add(s.ttl)}
+	s.mu.Unlock()
+	return id, nil
+}
+
+func (s *MemoryStore) Get(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.data[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if time.Now().After(sess.ExpiresAt) {
+		delete(s.data, id)
+		return nil, ErrSessionNotFound
+	}
+	return sess, nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.data, id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Touch(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.data[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if time.Now().After(sess.ExpiresAt) {
+		delete(s.data, id)
+		return ErrSessionNotFound
+	}
+	sess.ExpiresAt = time.Now().Add(s.ttl)
+	return nil
+}
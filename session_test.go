@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreSweepOnceRemovesExpiredSessions(t *testing.T) {
+	s := &MemoryStore{ttl: time.Minute, data: map[string]*Session{}}
+	s.data["expired"] = &Session{ExpiresAt: time.Now().Add(-time.Second)}
+	s.data["fresh"] = &Session{ExpiresAt: time.Now().Add(time.Minute)}
+
+	s.sweepOnce()
+
+	if _, ok := s.data["expired"]; ok {
+		t.Error("过期的 Session 应当被 sweepOnce 清理掉")
+	}
+	if _, ok := s.data["fresh"]; !ok {
+		t.Error("未过期的 Session 不应当被清理")
+	}
+}